@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/google/go-github/github"
+)
+
+// githubAPI is the narrow slice of the GitHub API that the tool's mutating
+// and listing operations need. It exists so that createMilestone,
+// createRelease, listCommits, getMilestone and the rollover/check helpers
+// can be exercised with a fake in tests instead of hitting github.com.
+// Listing methods resolve pagination internally, which keeps fakes simple.
+type githubAPI interface {
+	GetIssue(ctx context.Context, owner, repo string, number int) (*github.Issue, error)
+	EditIssue(ctx context.Context, owner, repo string, number int, req *github.IssueRequest) (*github.Issue, error)
+	ListMilestoneIssues(ctx context.Context, owner, repo string, milestoneNumber int, state string) ([]*github.Issue, error)
+	CompareCommits(ctx context.Context, owner, repo, base, head string) ([]github.RepositoryCommit, error)
+	CreateMilestone(ctx context.Context, owner, repo string, milestone *github.Milestone) (*github.Milestone, error)
+	EditMilestone(ctx context.Context, owner, repo string, number int, milestone *github.Milestone) (*github.Milestone, error)
+	CreateRelease(ctx context.Context, owner, repo string, release *github.RepositoryRelease) (*github.RepositoryRelease, error)
+	ListMilestones(ctx context.Context, owner, repo string) ([]*github.Milestone, error)
+	RemoveIssueLabel(ctx context.Context, owner, repo string, number int, label string) error
+}
+
+// restAPI implements githubAPI on top of the regular, paginated REST API.
+type restAPI struct {
+	client *github.Client
+}
+
+func (a *restAPI) GetIssue(ctx context.Context, owner, repo string, number int) (*github.Issue, error) {
+	issue, _, err := a.client.Issues.Get(ctx, owner, repo, number)
+	return issue, err
+}
+
+func (a *restAPI) EditIssue(ctx context.Context, owner, repo string, number int, req *github.IssueRequest) (*github.Issue, error) {
+	issue, _, err := a.client.Issues.Edit(ctx, owner, repo, number, req)
+	return issue, err
+}
+
+func (a *restAPI) ListMilestoneIssues(ctx context.Context, owner, repo string, milestoneNumber int, state string) ([]*github.Issue, error) {
+	opts := &github.IssueListByRepoOptions{
+		Milestone: strconv.Itoa(milestoneNumber),
+		State:     state,
+	}
+	var issues []*github.Issue
+	for {
+		is, resp, err := a.client.Issues.ListByRepo(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, is...)
+		if resp.NextPage <= opts.Page {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return issues, nil
+}
+
+func (a *restAPI) CompareCommits(ctx context.Context, owner, repo, base, head string) ([]github.RepositoryCommit, error) {
+	commits, _, err := a.client.Repositories.CompareCommits(ctx, owner, repo, base, head)
+	if err != nil {
+		return nil, err
+	}
+	return commits.Commits, nil
+}
+
+func (a *restAPI) CreateMilestone(ctx context.Context, owner, repo string, milestone *github.Milestone) (*github.Milestone, error) {
+	stone, _, err := a.client.Issues.CreateMilestone(ctx, owner, repo, milestone)
+	return stone, err
+}
+
+func (a *restAPI) EditMilestone(ctx context.Context, owner, repo string, number int, milestone *github.Milestone) (*github.Milestone, error) {
+	stone, _, err := a.client.Issues.EditMilestone(ctx, owner, repo, number, milestone)
+	return stone, err
+}
+
+func (a *restAPI) CreateRelease(ctx context.Context, owner, repo string, release *github.RepositoryRelease) (*github.RepositoryRelease, error) {
+	rel, _, err := a.client.Repositories.CreateRelease(ctx, owner, repo, release)
+	return rel, err
+}
+
+func (a *restAPI) ListMilestones(ctx context.Context, owner, repo string) ([]*github.Milestone, error) {
+	opts := &github.MilestoneListOptions{State: "all"}
+	var stones []*github.Milestone
+	for {
+		ms, resp, err := a.client.Issues.ListMilestones(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		stones = append(stones, ms...)
+		if resp.NextPage <= opts.Page {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return stones, nil
+}
+
+func (a *restAPI) RemoveIssueLabel(ctx context.Context, owner, repo string, number int, label string) error {
+	_, err := a.client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, label)
+	return err
+}
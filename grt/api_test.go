@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// fakeAPI is an in-memory githubAPI backed by plain maps, used to exercise
+// the tool without hitting github.com.
+type fakeAPI struct {
+	milestones map[int]*github.Milestone
+	issues     map[int]*github.Issue
+	commits    []github.RepositoryCommit
+	releases   []*github.RepositoryRelease
+	nextID     int
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{
+		milestones: make(map[int]*github.Milestone),
+		issues:     make(map[int]*github.Issue),
+	}
+}
+
+func (f *fakeAPI) addMilestone(title, state string) *github.Milestone {
+	f.nextID++
+	stone := &github.Milestone{
+		Number: github.Int(f.nextID),
+		Title:  github.String(title),
+		State:  github.String(state),
+	}
+	f.milestones[f.nextID] = stone
+	return stone
+}
+
+func (f *fakeAPI) addIssue(number int, state string, stone *github.Milestone, labelNames ...string) *github.Issue {
+	labels := make([]github.Label, len(labelNames))
+	for i, n := range labelNames {
+		labels[i] = github.Label{Name: github.String(n)}
+	}
+	issue := &github.Issue{
+		Number:    github.Int(number),
+		Title:     github.String(fmt.Sprintf("issue %d", number)),
+		State:     github.String(state),
+		Milestone: stone,
+		Labels:    labels,
+	}
+	f.issues[number] = issue
+	return issue
+}
+
+func (f *fakeAPI) GetIssue(ctx context.Context, owner, repo string, number int) (*github.Issue, error) {
+	issue, ok := f.issues[number]
+	if !ok {
+		return nil, fmt.Errorf("issue #%d not found", number)
+	}
+	return issue, nil
+}
+
+func (f *fakeAPI) EditIssue(ctx context.Context, owner, repo string, number int, req *github.IssueRequest) (*github.Issue, error) {
+	issue, ok := f.issues[number]
+	if !ok {
+		return nil, fmt.Errorf("issue #%d not found", number)
+	}
+	if req.Milestone != nil {
+		issue.Milestone = f.milestones[*req.Milestone]
+	}
+	return issue, nil
+}
+
+func (f *fakeAPI) ListMilestoneIssues(ctx context.Context, owner, repo string, milestoneNumber int, state string) ([]*github.Issue, error) {
+	var out []*github.Issue
+	for _, issue := range f.issues {
+		if issue.Milestone.GetNumber() != milestoneNumber {
+			continue
+		}
+		if state != "all" && issue.GetState() != state {
+			continue
+		}
+		out = append(out, issue)
+	}
+	return out, nil
+}
+
+func (f *fakeAPI) CompareCommits(ctx context.Context, owner, repo, base, head string) ([]github.RepositoryCommit, error) {
+	return f.commits, nil
+}
+
+func (f *fakeAPI) CreateMilestone(ctx context.Context, owner, repo string, milestone *github.Milestone) (*github.Milestone, error) {
+	f.nextID++
+	milestone.Number = github.Int(f.nextID)
+	f.milestones[f.nextID] = milestone
+	return milestone, nil
+}
+
+func (f *fakeAPI) EditMilestone(ctx context.Context, owner, repo string, number int, milestone *github.Milestone) (*github.Milestone, error) {
+	stone, ok := f.milestones[number]
+	if !ok {
+		return nil, fmt.Errorf("milestone #%d not found", number)
+	}
+	if milestone.State != nil {
+		stone.State = milestone.State
+	}
+	return stone, nil
+}
+
+func (f *fakeAPI) CreateRelease(ctx context.Context, owner, repo string, release *github.RepositoryRelease) (*github.RepositoryRelease, error) {
+	f.releases = append(f.releases, release)
+	return release, nil
+}
+
+func (f *fakeAPI) ListMilestones(ctx context.Context, owner, repo string) ([]*github.Milestone, error) {
+	var out []*github.Milestone
+	for _, stone := range f.milestones {
+		out = append(out, stone)
+	}
+	return out, nil
+}
+
+func (f *fakeAPI) RemoveIssueLabel(ctx context.Context, owner, repo string, number int, label string) error {
+	issue, ok := f.issues[number]
+	if !ok {
+		return fmt.Errorf("issue #%d not found", number)
+	}
+	kept := issue.Labels[:0]
+	for _, l := range issue.Labels {
+		if l.GetName() != label {
+			kept = append(kept, l)
+		}
+	}
+	issue.Labels = kept
+	return nil
+}
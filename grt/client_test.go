@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// fakeClient is an in-memory githubClient used to test changelog without a
+// fakeAPI round-trip.
+type fakeClient struct {
+	milestone *github.Milestone
+	issues    []*github.Issue
+}
+
+func (f *fakeClient) FetchMilestone(ctx context.Context, owner, repo, title string) (*github.Milestone, error) {
+	if f.milestone == nil || f.milestone.GetTitle() != title {
+		return nil, errors.New("not found")
+	}
+	return f.milestone, nil
+}
+
+func (f *fakeClient) FetchMilestoneIssues(ctx context.Context, owner, repo string, milestoneNumber int, state string) ([]*github.Issue, error) {
+	var out []*github.Issue
+	for _, issue := range f.issues {
+		if state != "all" && issue.GetState() != state {
+			continue
+		}
+		out = append(out, issue)
+	}
+	return out, nil
+}
+
+func issue(number int, title, state string, labelNames ...string) *github.Issue {
+	labels := make([]github.Label, len(labelNames))
+	for i, n := range labelNames {
+		labels[i] = github.Label{Name: github.String(n)}
+	}
+	return &github.Issue{
+		Number: github.Int(number),
+		Title:  github.String(title),
+		State:  github.String(state),
+		Labels: labels,
+	}
+}
+
+func TestChangelogBucketsByLabel(t *testing.T) {
+	gh := &fakeClient{
+		milestone: &github.Milestone{Number: github.Int(1), Title: github.String("v1.2.0")},
+		issues: []*github.Issue{
+			issue(1, "a crash", "closed", "bug"),
+			issue(2, "a new thing", "closed", "enhancement"),
+			issue(3, "some cleanup", "closed"),
+		},
+	}
+
+	var buf bytes.Buffer
+	format, err := parseFormat("text", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changelog(context.Background(), &buf, gh, nil, "o", "r", "v1.2.0", format, nil, "", "", nil, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"Bugfixes:", "#1: a crash", "Enhancements:", "#2: a new thing", "Other issues:", "#3: some cleanup"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("changelog output missing %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestChangelogSkipsLabelsAndPullRequests(t *testing.T) {
+	wontfix := issue(1, "not going to do it", "closed", "bug", "wontfix")
+	pr := issue(2, "a pull request", "closed", "bug")
+	pr.PullRequestLinks = &github.PullRequestLinks{}
+
+	gh := &fakeClient{
+		milestone: &github.Milestone{Number: github.Int(1), Title: github.String("v1.2.0")},
+		issues:    []*github.Issue{wontfix, pr, issue(3, "a real bug", "closed", "bug")},
+	}
+
+	var buf bytes.Buffer
+	format, err := parseFormat("text", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changelog(context.Background(), &buf, gh, nil, "o", "r", "v1.2.0", format, []string{"wontfix"}, "", "", nil, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "not going to do it") {
+		t.Errorf("skip-labelled issue should have been filtered out, got:\n%s", out)
+	}
+	if strings.Contains(out, "a pull request") {
+		t.Errorf("pull request should have been filtered out, got:\n%s", out)
+	}
+	if !strings.Contains(out, "a real bug") {
+		t.Errorf("expected real bug issue in output, got:\n%s", out)
+	}
+}
+
+func TestChangelogJSONFormat(t *testing.T) {
+	gh := &fakeClient{
+		milestone: &github.Milestone{Number: github.Int(1), Title: github.String("v1.2.0")},
+		issues: []*github.Issue{
+			issue(1, "a crash", "closed", "bug"),
+			issue(2, "a new thing", "closed", "enhancement"),
+		},
+	}
+
+	var buf bytes.Buffer
+	format, err := parseFormat("json", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changelog(context.Background(), &buf, gh, nil, "o", "r", "v1.2.0", format, nil, "", "", nil, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var data changelogData
+	if err := json.Unmarshal(buf.Bytes(), &data); err != nil {
+		t.Fatalf("unmarshaling changelog JSON: %v\n%s", err, buf.String())
+	}
+	if data.Release != "v1.2.0" || len(data.Sections) != 2 {
+		t.Fatalf("unexpected changelog JSON: %+v", data)
+	}
+	if data.Sections[0].Name != "Bugfixes" || data.Sections[0].Issues[0].Number != 1 {
+		t.Errorf("expected Bugfixes section with issue #1, got %+v", data.Sections[0])
+	}
+}
+
+func TestParseFormatRejectsBadGroupBy(t *testing.T) {
+	if _, err := parseFormat("text", "labels", nil); err == nil {
+		t.Error("expected an error for an unknown --group-by value")
+	}
+	if _, err := parseFormat("text", "", []string{"bug"}); err == nil {
+		t.Error("expected an error for --section without --group-by=label")
+	}
+}
+
+func TestChangelogGroupByLabel(t *testing.T) {
+	gh := &fakeClient{
+		milestone: &github.Milestone{Number: github.Int(1), Title: github.String("v1.2.0")},
+		issues: []*github.Issue{
+			issue(1, "a security fix", "closed", "security"),
+			issue(2, "a crash", "closed", "bug"),
+			issue(3, "unsorted", "closed"),
+		},
+	}
+
+	var buf bytes.Buffer
+	format, err := parseFormat("text", "label", []string{"security", "bug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changelog(context.Background(), &buf, gh, nil, "o", "r", "v1.2.0", format, nil, "", "", nil, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Index(out, "security:") > strings.Index(out, "#1: a security fix") {
+		t.Errorf("expected 'security' section before its issue, got:\n%s", out)
+	}
+	for _, want := range []string{"security:", "#1: a security fix", "bug:", "#2: a crash", "Other issues:", "#3: unsorted"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("changelog output missing %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestChangelogAlsoReferencedSection(t *testing.T) {
+	api := newFakeAPI()
+	stone := api.addMilestone("v1.2.0", "open")
+	api.addIssue(1, "closed", stone, "bug")
+	api.addIssue(56, "open", nil)
+	api.commits = []github.RepositoryCommit{commitWithMessage("lib: tidy up\n\nUpdates #56")}
+	gh := &restClient{api: api}
+
+	var buf bytes.Buffer
+	format, err := parseFormat("text", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changelog(context.Background(), &buf, gh, api, "o", "r", "v1.2.0", format, nil, "v1", "HEAD", nil, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "Also referenced:") || !strings.Contains(out, "#56: issue 56") {
+		t.Errorf("expected an Also referenced section listing #56, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Bugfixes:") || !strings.Contains(out, "#1: issue 1") {
+		t.Errorf("expected issue #1 to still be milestoned under Bugfixes, got:\n%s", out)
+	}
+}
+
+func TestChangelogAlsoReferencedFiltersSkipLabelsAndPullRequests(t *testing.T) {
+	api := newFakeAPI()
+	api.addMilestone("v1.2.0", "open")
+	api.addIssue(56, "open", nil, "wontfix")
+	pr := api.addIssue(57, "closed", nil)
+	pr.PullRequestLinks = &github.PullRequestLinks{}
+	api.addIssue(58, "open", nil)
+	api.commits = []github.RepositoryCommit{commitWithMessage(
+		"lib: tidy up\n\nUpdates #56\nUpdates #57\nUpdates #58",
+	)}
+	gh := &restClient{api: api}
+
+	var buf bytes.Buffer
+	format, err := parseFormat("text", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changelog(context.Background(), &buf, gh, api, "o", "r", "v1.2.0", format, []string{"wontfix"}, "v1", "HEAD", nil, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "#56") {
+		t.Errorf("skip-labelled issue should not appear under Also referenced, got:\n%s", out)
+	}
+	if strings.Contains(out, "#57") {
+		t.Errorf("pull request should not appear under Also referenced, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Also referenced:") || !strings.Contains(out, "#58: issue 58") {
+		t.Errorf("expected Also referenced to list #58, got:\n%s", out)
+	}
+}
+
+func TestChangelogTemplateFormat(t *testing.T) {
+	gh := &fakeClient{
+		milestone: &github.Milestone{Number: github.Int(1), Title: github.String("v1.2.0")},
+		issues:    []*github.Issue{issue(1, "a crash", "closed", "bug")},
+	}
+
+	dir := t.TempDir()
+	tmplPath := filepath.Join(dir, "changelog.tmpl")
+	tmpl := `{{.Release}}:{{range .Sections}}{{range .Issues}} #{{.Number}}{{end}}{{end}}`
+	if err := os.WriteFile(tmplPath, []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	format, err := parseFormat("template="+tmplPath, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changelog(context.Background(), &buf, gh, nil, "o", "r", "v1.2.0", format, nil, "", "", nil, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "v1.2.0: #1"; buf.String() != want {
+		t.Errorf("changelog template output = %q, want %q", buf.String(), want)
+	}
+}
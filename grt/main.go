@@ -3,19 +3,23 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/alecthomas/kong"
 	"github.com/google/go-github/github"
+	"github.com/shurcooL/githubv4"
 	"golang.org/x/oauth2"
 )
 
@@ -25,14 +29,18 @@ type cliOptions struct {
 	Milestone milestoneOptions `cmd:"" help:"Collect resolved issues into milestone"`
 	Changelog changelogOptions `cmd:"" help:"Show changelog for milestone"`
 	Release   releaseOptions   `cmd:"" help:"Create release from milestone"`
+	Check     checkOptions     `cmd:"" help:"Check a milestone for unresolved release blockers"`
+	Rollover  rolloverOptions  `cmd:"" help:"Roll open issues over to the next milestone, or lift a pre-release waiver"`
 }
 
 type commonOptions struct {
 	Owner string `required:"" env:"GRT_OWNER" help:"Owner name"`
 	Repo  string `required:"" env:"GRT_REPO" help:"Repository name"`
+	API   string `enum:"rest,graphql" default:"rest" help:"API to use for fetching milestones and issues: rest or graphql"`
 
-	ctx    context.Context
-	client *github.Client
+	ctx context.Context
+	api githubAPI
+	gh  githubClient
 }
 
 type dryRunFlag struct {
@@ -49,23 +57,45 @@ type releaseArg struct {
 
 type milestoneOptions struct {
 	dryRunFlag
-	From      string `placeholder:"TAG/COMMIT" help:"Start tag/commit"`
-	To        string `placeholder:"TAG/COMMIT" default:"HEAD" help:"End tag/commit"`
-	Force     bool   `help:"Overwrite milestone on already milestoned issues"`
-	Milestone string `arg:"" required:"" help:"The milestone name"`
+	From       string   `placeholder:"TAG/COMMIT" help:"Start tag/commit"`
+	To         string   `placeholder:"TAG/COMMIT" default:"HEAD" help:"End tag/commit"`
+	Force      bool     `help:"Overwrite milestone on already milestoned issues"`
+	FixKeyword []string `placeholder:"KEYWORD" help:"Extra closing keyword to recognize, in addition to the GitHub defaults (fixes, closes, resolves, ...)"`
+	Trailer    []string `placeholder:"KEYWORD" help:"Extra keyword that references an issue without closing it, in addition to the default 'updates'"`
+	Milestone  string   `arg:"" required:"" help:"The milestone name"`
 }
 
 type changelogOptions struct {
 	skipLabelFlag
 	releaseArg
-	Md        bool   `help:"Markdown links"`
-	SkipLabel string `placeholder:"LABEL" env:"GRT_SKIPLABELS" help:"Issue labels to skip"`
+	SkipLabel  string   `placeholder:"LABEL" env:"GRT_SKIPLABELS" help:"Issue labels to skip"`
+	Format     string   `default:"text" help:"Output format: text, md, json, or template=FILE"`
+	GroupBy    string   `placeholder:"label" help:"Group issues into sections ordered by --section instead of the fixed bugs/enhancements/other buckets"`
+	Section    []string `placeholder:"LABEL" help:"Label, in display order, for --group-by=label (repeatable)"`
+	From       string   `placeholder:"TAG/COMMIT" help:"Start tag/commit to scan for Updates-style references; enables the Also referenced section"`
+	To         string   `placeholder:"TAG/COMMIT" default:"HEAD" help:"End tag/commit to scan for Updates-style references"`
+	FixKeyword []string `placeholder:"KEYWORD" help:"Extra closing keyword to recognize, in addition to the GitHub defaults (fixes, closes, resolves, ...)"`
+	Trailer    []string `placeholder:"KEYWORD" help:"Extra keyword that references an issue without closing it, in addition to the default 'updates'"`
 }
 
 type releaseOptions struct {
 	dryRunFlag
 	skipLabelFlag
 	releaseArg
+	Next        string `placeholder:"MILESTONE" help:"Next milestone to move open issues to, when closing a final release"`
+	WaiverLabel string `placeholder:"LABEL" help:"Label to remove from milestone issues after a pre-release (default okay-after-<suffix>)"`
+}
+
+type checkOptions struct {
+	releaseArg
+	Allow       []int  `placeholder:"N" help:"Issue numbers to allow even if they are release blockers"`
+	WaiverLabel string `placeholder:"LABEL" help:"Label that waives a release blocker for this pre-release (default okay-after-<suffix>)"`
+}
+
+type rolloverOptions struct {
+	releaseArg
+	Next        string `placeholder:"MILESTONE" help:"Next milestone to move open issues to, when rolling over a final release"`
+	WaiverLabel string `placeholder:"LABEL" help:"Label to remove from milestone issues after a pre-release (default okay-after-<suffix>)"`
 }
 
 func main() {
@@ -81,51 +111,80 @@ func main() {
 	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
 		tc = oauth2.NewClient(cli.ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
 	}
-	cli.client = github.NewClient(tc)
+	cli.api = &restAPI{client: github.NewClient(tc)}
+
+	if cli.API == "graphql" {
+		if tc == nil {
+			tc = http.DefaultClient
+		}
+		cli.gh = &graphqlClient{v4: githubv4.NewClient(tc)}
+	} else {
+		cli.gh = &restClient{api: cli.api}
+	}
 
 	cmd := kong.Parse(&cli)
 	cmd.FatalIfErrorf(cmd.Run(&cli.commonOptions))
 }
 
 func (o *milestoneOptions) Run(common *commonOptions) error {
-	return createMilestone(common.ctx, common.client, common.Owner, common.Repo, o.From, o.To, o.Milestone, o.Force, o.DryRun)
+	return createMilestone(common.ctx, common.api, common.Owner, common.Repo, o.From, o.To, o.Milestone, o.Force, o.DryRun, o.FixKeyword, o.Trailer)
 }
 
 func (o changelogOptions) Run(common *commonOptions) error {
-	return changelog(common.ctx, os.Stdout, common.client, common.Owner, common.Repo, o.Release, o.Md, o.SkipLabels, true)
-
+	format, err := parseFormat(o.Format, o.GroupBy, o.Section)
+	if err != nil {
+		return err
+	}
+	return changelog(common.ctx, os.Stdout, common.gh, common.api, common.Owner, common.Repo, o.Release, format, o.SkipLabels, o.From, o.To, o.FixKeyword, o.Trailer, true)
 }
 
 func (o releaseOptions) Run(common *commonOptions) error {
 	buf := new(bytes.Buffer)
-	if err := changelog(common.ctx, buf, common.client, common.Owner, common.Repo, o.Release, false, o.SkipLabels, false); err != nil {
+	format, err := parseFormat("text", "", nil)
+	if err != nil {
 		return err
 	}
-	return createRelease(common.ctx, common.client, common.Owner, common.Repo, o.Release, buf.String())
+	if err := changelog(common.ctx, buf, common.gh, common.api, common.Owner, common.Repo, o.Release, format, o.SkipLabels, "", "", nil, nil, false); err != nil {
+		return err
+	}
+	return createRelease(common.ctx, common.api, common.Owner, common.Repo, o.Release, o.Next, o.WaiverLabel, buf.String())
+}
+
+func (o checkOptions) Run(common *commonOptions) error {
+	return checkBlockers(common.ctx, common.api, common.Owner, common.Repo, o.Release, o.Allow, o.WaiverLabel)
 }
 
-func createMilestone(ctx context.Context, client *github.Client, owner, repo, since, to, milestone string, force, dryRun bool) error {
-	stone, err := getMilestone(ctx, client, owner, repo, milestone)
+func (o rolloverOptions) Run(common *commonOptions) error {
+	return rollover(common.ctx, common.api, common.Owner, common.Repo, o.Release, o.Next, o.WaiverLabel)
+}
+
+func createMilestone(ctx context.Context, api githubAPI, owner, repo, since, to, milestone string, force, dryRun bool, fixKeywords, trailers []string) error {
+	stone, err := getMilestone(ctx, api, owner, repo, milestone)
 	if err != nil {
 		log.Println("Creating milestone", milestone)
 		if !dryRun {
 			stone = &github.Milestone{
 				Title: github.String(milestone),
 			}
-			stone, _, err = client.Issues.CreateMilestone(ctx, owner, repo, stone)
+			stone, err = api.CreateMilestone(ctx, owner, repo, stone)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
-	commits, err := listCommits(ctx, client, owner, repo, since, to)
+	commits, err := listCommits(ctx, api, owner, repo, since, to)
 	if err != nil {
 		return fmt.Errorf("listing commits: %w", err)
 	}
 
-	for _, fix := range getFixes(commits) {
-		issue, _, err := client.Issues.Get(ctx, owner, repo, fix)
+	fixes, updates := getFixes(commits, owner, repo, fixKeywords, trailers)
+	for _, ref := range updates {
+		log.Println("Issue", ref, "is referenced but not closed; not marking")
+	}
+
+	for _, fix := range fixes {
+		issue, err := api.GetIssue(ctx, owner, repo, fix)
 		if err != nil {
 			log.Println("Getting issue:", err)
 			continue
@@ -149,7 +208,7 @@ func createMilestone(ctx context.Context, client *github.Client, owner, repo, si
 		// Set the issue milestone.
 		log.Println("Marking issue", fix)
 		if !dryRun {
-			_, _, err = client.Issues.Edit(ctx, owner, repo, issue.GetNumber(), &github.IssueRequest{
+			_, err = api.EditIssue(ctx, owner, repo, issue.GetNumber(), &github.IssueRequest{
 				Milestone: github.Int(stone.GetNumber()),
 			})
 			if err != nil {
@@ -161,108 +220,275 @@ func createMilestone(ctx context.Context, client *github.Client, owner, repo, si
 	return nil
 }
 
-func changelog(ctx context.Context, w io.Writer, client *github.Client, owner, repo, release string, markdownLinks bool, skipLabels []string, withSubject bool) error {
-	milestone := strings.SplitN(release, "-", 2)[0]
+// changelogFormat controls how changelog renders the issues it collects:
+// as the built-in text or Markdown layout, as JSON, or through a
+// user-supplied text/template file, and optionally grouped into
+// user-ordered label sections instead of the fixed bugs/enhancements/other
+// buckets.
+type changelogFormat struct {
+	Kind         string // "text", "md", "json", or "template"
+	TemplateFile string // set when Kind == "template"
+	GroupBy      string // "" or "label"
+	Sections     []string
+}
+
+// parseFormat parses the --format and --group-by/--section flags into a
+// changelogFormat. raw recognizes "text" (the default), "md", "json", and
+// "template=FILE".
+func parseFormat(raw, groupBy string, sections []string) (changelogFormat, error) {
+	switch groupBy {
+	case "", "label":
+	default:
+		return changelogFormat{}, fmt.Errorf("unknown --group-by %q", groupBy)
+	}
+	if groupBy != "label" && len(sections) > 0 {
+		return changelogFormat{}, errors.New("--section requires --group-by=label")
+	}
+
+	format := changelogFormat{GroupBy: groupBy, Sections: sections}
+	switch {
+	case raw == "" || raw == "text":
+		format.Kind = "text"
+	case raw == "md":
+		format.Kind = "md"
+	case raw == "json":
+		format.Kind = "json"
+	case strings.HasPrefix(raw, "template="):
+		file := strings.TrimPrefix(raw, "template=")
+		if file == "" {
+			return changelogFormat{}, errors.New("--format=template= requires a file")
+		}
+		format.Kind = "template"
+		format.TemplateFile = file
+	default:
+		return changelogFormat{}, fmt.Errorf("unknown --format %q", raw)
+	}
+	return format, nil
+}
+
+// changelogIssue is the per-issue data made available to JSON output and
+// --format=template templates.
+type changelogIssue struct {
+	Number int
+	Title  string
+	URL    string
+	Author string
+	Labels []string
+}
+
+// changelogSection is a named, ordered group of issues: one of the fixed
+// Bugfixes/Enhancements/Other issues buckets, or one of the user-ordered
+// --section buckets under --group-by=label.
+type changelogSection struct {
+	Name   string
+	Issues []changelogIssue
+}
+
+// changelogData is the full set of data rendered for a release: everything
+// JSON output and --format=template templates see.
+type changelogData struct {
+	Release     string
+	Milestone   string
+	Description string
+	Sections    []changelogSection
+}
+
+func changelog(ctx context.Context, w io.Writer, gh githubClient, api githubAPI, owner, repo, release string, format changelogFormat, skipLabels []string, since, to string, fixKeywords, trailers []string, withSubject bool) error {
+	milestone, _ := splitRelease(release)
 
-	stone, err := getMilestone(ctx, client, owner, repo, milestone)
+	stone, err := gh.FetchMilestone(ctx, owner, repo, milestone)
 	if err != nil {
 		return fmt.Errorf("getting milestone: %w", err)
 	}
 
-	opts := &github.IssueListByRepoOptions{
-		Milestone: strconv.Itoa(stone.GetNumber()),
-		State:     "all",
-	}
-	var issues []*github.Issue
-	for {
-		is, resp, err := client.Issues.ListByRepo(ctx, owner, repo, opts)
-		if err != nil {
-			return fmt.Errorf("listing issues: %w", err)
-		}
-		issues = append(issues, is...)
-		if resp.NextPage <= opts.Page {
-			break
-		}
-		opts.Page = resp.NextPage
+	allIssues, err := gh.FetchMilestoneIssues(ctx, owner, repo, stone.GetNumber(), "all")
+	if err != nil {
+		return fmt.Errorf("listing issues: %w", err)
 	}
 
-	sort.Slice(issues, func(a, b int) bool {
-		return issues[a].GetNumber() < issues[b].GetNumber()
+	sort.Slice(allIssues, func(a, b int) bool {
+		return allIssues[a].GetNumber() < allIssues[b].GetNumber()
 	})
 
-	var bugs, enhancements, other []*github.Issue
+	var issues []*github.Issue
 nextIssue:
-	for _, issue := range issues {
+	for _, issue := range allIssues {
 		if issue.IsPullRequest() {
 			continue
 		}
-
 		labels := labels(issue)
 		for _, skip := range skipLabels {
 			if contains(skip, labels) {
 				continue nextIssue
 			}
 		}
+		issues = append(issues, issue)
+	}
+
+	sections := bucketIssues(issues, format.GroupBy, format.Sections)
+
+	if since != "" {
+		exclude := make(map[int]struct{}, len(issues))
+		for _, issue := range issues {
+			exclude[issue.GetNumber()] = struct{}{}
+		}
+		refs, err := referencedIssues(ctx, api, owner, repo, since, to, fixKeywords, trailers, skipLabels, exclude)
+		if err != nil {
+			return fmt.Errorf("finding referenced issues: %w", err)
+		}
+		if len(refs) > 0 {
+			sections = append(sections, changelogSection{"Also referenced", refs})
+		}
+	}
 
+	data := changelogData{
+		Release:     release,
+		Milestone:   milestone,
+		Description: strings.TrimSpace(stone.GetDescription()),
+		Sections:    sections,
+	}
+
+	switch format.Kind {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case "template":
+		return renderChangelogTemplate(w, data, format.TemplateFile)
+	default:
+		renderChangelogText(w, data, format.Kind == "md", withSubject, owner, repo)
+		return nil
+	}
+}
+
+// bucketIssues groups issues into sections: the fixed bugs/enhancements/
+// other buckets by default, or one section per label in sections, in order,
+// when groupBy is "label". Under --group-by=label, an issue is placed in
+// the first matching section; issues matching none of sections fall back
+// to a trailing "Other issues" section.
+func bucketIssues(issues []*github.Issue, groupBy string, sections []string) []changelogSection {
+	if groupBy == "label" && len(sections) > 0 {
+		return bucketByLabel(issues, sections)
+	}
+
+	var bugs, enhancements, other []changelogIssue
+	for _, issue := range issues {
+		ci := toChangelogIssue(issue)
 		switch {
-		case contains("bug", labels):
-			bugs = append(bugs, issue)
-		case contains("enhancement", labels):
-			enhancements = append(enhancements, issue)
+		case contains("bug", ci.Labels):
+			bugs = append(bugs, ci)
+		case contains("enhancement", ci.Labels):
+			enhancements = append(enhancements, ci)
 		default:
-			other = append(other, issue)
+			other = append(other, ci)
 		}
 	}
 
-	if withSubject {
-		if markdownLinks {
-			fmt.Fprintf(w, "# [%s](https://github.com/%s/%s/releases/%s)\n\n", release, owner, repo, release)
-		} else {
-			fmt.Fprintf(w, "%s\n\n", release)
+	var out []changelogSection
+	if len(bugs) > 0 {
+		out = append(out, changelogSection{"Bugfixes", bugs})
+	}
+	if len(enhancements) > 0 {
+		out = append(out, changelogSection{"Enhancements", enhancements})
+	}
+	if len(other) > 0 {
+		out = append(out, changelogSection{"Other issues", other})
+	}
+	return out
+}
+
+func bucketByLabel(issues []*github.Issue, sectionLabels []string) []changelogSection {
+	assigned := make(map[int]struct{}, len(issues))
+	buckets := make(map[string][]changelogIssue, len(sectionLabels))
+	for _, label := range sectionLabels {
+		for _, issue := range issues {
+			if _, ok := assigned[issue.GetNumber()]; ok {
+				continue
+			}
+			if contains(label, labels(issue)) {
+				buckets[label] = append(buckets[label], toChangelogIssue(issue))
+				assigned[issue.GetNumber()] = struct{}{}
+			}
 		}
 	}
 
-	if descr := stone.GetDescription(); descr != "" {
-		descr := wrap(strings.TrimSpace(descr), 72)
-		fmt.Fprintf(w, "%s\n\n", descr)
+	var out []changelogSection
+	for _, label := range sectionLabels {
+		if len(buckets[label]) > 0 {
+			out = append(out, changelogSection{label, buckets[label]})
+		}
 	}
 
-	if len(bugs) > 0 {
-		if markdownLinks {
-			fmt.Fprintf(w, "## Bugfixes\n\n")
-		} else {
-			fmt.Fprintf(w, "Bugfixes:\n\n")
+	var other []changelogIssue
+	for _, issue := range issues {
+		if _, ok := assigned[issue.GetNumber()]; !ok {
+			other = append(other, toChangelogIssue(issue))
 		}
-		printIssues(w, bugs, markdownLinks)
-		fmt.Fprintf(w, "\n")
 	}
-	if len(enhancements) > 0 {
+	if len(other) > 0 {
+		out = append(out, changelogSection{"Other issues", other})
+	}
+	return out
+}
+
+func toChangelogIssue(issue *github.Issue) changelogIssue {
+	return changelogIssue{
+		Number: issue.GetNumber(),
+		Title:  issue.GetTitle(),
+		URL:    issue.GetHTMLURL(),
+		Author: issue.GetUser().GetLogin(),
+		Labels: labels(issue),
+	}
+}
+
+func renderChangelogText(w io.Writer, data changelogData, markdownLinks, withSubject bool, owner, repo string) {
+	if withSubject {
 		if markdownLinks {
-			fmt.Fprintf(w, "## Enhancements\n\n")
+			fmt.Fprintf(w, "# [%s](https://github.com/%s/%s/releases/%s)\n\n", data.Release, owner, repo, data.Release)
 		} else {
-			fmt.Fprintf(w, "Enhancements:\n\n")
+			fmt.Fprintf(w, "%s\n\n", data.Release)
 		}
-		printIssues(w, enhancements, markdownLinks)
-		fmt.Fprintf(w, "\n")
 	}
-	if len(other) > 0 {
+
+	if data.Description != "" {
+		fmt.Fprintf(w, "%s\n\n", wrap(data.Description, 72))
+	}
+
+	for _, section := range data.Sections {
 		if markdownLinks {
-			fmt.Fprintf(w, "## Other issues\n\n")
+			fmt.Fprintf(w, "## %s\n\n", section.Name)
 		} else {
-			fmt.Fprintf(w, "Other issues:\n\n")
+			fmt.Fprintf(w, "%s:\n\n", section.Name)
 		}
-		printIssues(w, other, markdownLinks)
+		printIssues(w, section.Issues, markdownLinks)
 		fmt.Fprintf(w, "\n")
 	}
-	return nil
 }
 
-func createRelease(ctx context.Context, client *github.Client, owner, repo, release string, changelog string) error {
-	splits := strings.SplitN(release, "-", 2)
-	milestone := splits[0]
-	pre := release != milestone
+// renderChangelogTemplate parses file as a text/template and executes it
+// against data, so downstream projects can fully customize changelog
+// layout without a code change here.
+func renderChangelogTemplate(w io.Writer, data changelogData, file string) error {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(file)).Parse(string(raw))
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+	return tmpl.Execute(w, data)
+}
+
+func createRelease(ctx context.Context, api githubAPI, owner, repo, release, next, waiverLabel string, changelog string) error {
+	milestone, suffix := splitRelease(release)
+	pre := suffix != ""
+
+	if !pre && next == "" {
+		return errors.New("creating a final release requires --next")
+	}
 
-	stone, err := getMilestone(ctx, client, owner, repo, milestone)
+	stone, err := getMilestone(ctx, api, owner, repo, milestone)
 	if err != nil {
 		return fmt.Errorf("getting milestone: %w", err)
 	}
@@ -274,27 +500,182 @@ func createRelease(ctx context.Context, client *github.Client, owner, repo, rele
 		Prerelease: github.Bool(pre),
 		Draft:      github.Bool(false),
 	}
-	if _, _, err := client.Repositories.CreateRelease(ctx, owner, repo, rel); err != nil {
+	if _, err := api.CreateRelease(ctx, owner, repo, rel); err != nil {
 		return err
 	}
 
-	if !pre { // Close milestone
-		_, _, err := client.Issues.EditMilestone(ctx, owner, repo, stone.GetNumber(), &github.Milestone{
-			State: github.String("closed"),
+	if pre {
+		return removeWaiverLabel(ctx, api, owner, repo, stone, waiverFor(waiverLabel, suffix))
+	}
+	return rolloverIssues(ctx, api, owner, repo, stone, next)
+}
+
+// rollover moves the still-open issues of a closed final-release milestone
+// to the next milestone, the way Go's release automation pushes issues
+// forward. For a pre-release it instead lifts the waiver label so that
+// check re-flags any remaining blockers for the next pre-release.
+func rollover(ctx context.Context, api githubAPI, owner, repo, release, next, waiverLabel string) error {
+	milestone, suffix := splitRelease(release)
+
+	stone, err := getMilestone(ctx, api, owner, repo, milestone)
+	if err != nil {
+		return fmt.Errorf("getting milestone: %w", err)
+	}
+
+	if suffix != "" {
+		return removeWaiverLabel(ctx, api, owner, repo, stone, waiverFor(waiverLabel, suffix))
+	}
+
+	if next == "" {
+		return errors.New("rolling over a final release requires --next")
+	}
+	return rolloverIssues(ctx, api, owner, repo, stone, next)
+}
+
+// waiverFor returns label if set, otherwise the default waiver label name
+// for the given pre-release suffix.
+func waiverFor(label, suffix string) string {
+	if label != "" {
+		return label
+	}
+	return "okay-after-" + suffix
+}
+
+// removeWaiverLabel strips label from every open issue in stone's milestone
+// that carries it, so the check subcommand flags them again for the next
+// pre-release.
+func removeWaiverLabel(ctx context.Context, api githubAPI, owner, repo string, stone *github.Milestone, label string) error {
+	issues, err := api.ListMilestoneIssues(ctx, owner, repo, stone.GetNumber(), "open")
+	if err != nil {
+		return fmt.Errorf("listing issues: %w", err)
+	}
+	for _, issue := range issues {
+		if issue.IsPullRequest() || !contains(label, labels(issue)) {
+			continue
+		}
+		if err := api.RemoveIssueLabel(ctx, owner, repo, issue.GetNumber(), label); err != nil {
+			return fmt.Errorf("removing waiver label from issue #%d: %w", issue.GetNumber(), err)
+		}
+	}
+	return nil
+}
+
+// rolloverIssues moves every still-open issue in stone's milestone to the
+// named next milestone (creating it if necessary), then closes stone.
+func rolloverIssues(ctx context.Context, api githubAPI, owner, repo string, stone *github.Milestone, next string) error {
+	nextStone, err := getMilestone(ctx, api, owner, repo, next)
+	if err != nil {
+		nextStone, err = api.CreateMilestone(ctx, owner, repo, &github.Milestone{Title: github.String(next)})
+		if err != nil {
+			return fmt.Errorf("creating next milestone: %w", err)
+		}
+	}
+
+	issues, err := api.ListMilestoneIssues(ctx, owner, repo, stone.GetNumber(), "open")
+	if err != nil {
+		return fmt.Errorf("listing issues: %w", err)
+	}
+	for _, issue := range issues {
+		if issue.IsPullRequest() {
+			continue
+		}
+		_, err := api.EditIssue(ctx, owner, repo, issue.GetNumber(), &github.IssueRequest{
+			Milestone: github.Int(nextStone.GetNumber()),
 		})
 		if err != nil {
-			return fmt.Errorf("closing milestone: %w")
+			return fmt.Errorf("moving issue #%d to %s: %w", issue.GetNumber(), next, err)
 		}
 	}
+
+	if _, err := api.EditMilestone(ctx, owner, repo, stone.GetNumber(), &github.Milestone{
+		State: github.String("closed"),
+	}); err != nil {
+		return fmt.Errorf("closing milestone: %w", err)
+	}
 	return nil
 }
 
-func printIssues(w io.Writer, issues []*github.Issue, markdownLinks bool) {
+// checkBlockers looks for open issues in the release's milestone that carry
+// the release-blocker label and have not been waived for this specific
+// pre-release, printing a table of anything it finds. It returns a non-nil
+// error when any unresolved blockers remain.
+func checkBlockers(ctx context.Context, api githubAPI, owner, repo, release string, allow []int, waiverLabel string) error {
+	milestone, suffix := splitRelease(release)
+
+	stone, err := getMilestone(ctx, api, owner, repo, milestone)
+	if err != nil {
+		return fmt.Errorf("getting milestone: %w", err)
+	}
+
+	allowed := make(map[int]struct{}, len(allow))
+	for _, n := range allow {
+		allowed[n] = struct{}{}
+	}
+
+	issues, err := api.ListMilestoneIssues(ctx, owner, repo, stone.GetNumber(), "open")
+	if err != nil {
+		return fmt.Errorf("listing issues: %w", err)
+	}
+	var blockers []*github.Issue
+	for _, issue := range issues {
+		if issue.IsPullRequest() {
+			continue
+		}
+		if _, ok := allowed[issue.GetNumber()]; ok {
+			continue
+		}
+		ls := labels(issue)
+		if !contains("release-blocker", ls) || isWaived(ls, suffix, waiverLabel) {
+			continue
+		}
+		blockers = append(blockers, issue)
+	}
+
+	sort.Slice(blockers, func(a, b int) bool {
+		return blockers[a].GetNumber() < blockers[b].GetNumber()
+	})
+
+	if len(blockers) == 0 {
+		fmt.Println("No release blockers remain in", milestone)
+		return nil
+	}
+
+	fmt.Println("Unresolved release blockers:")
+	fmt.Println()
+	for _, issue := range blockers {
+		fmt.Printf("#%-6d %s\n", issue.GetNumber(), issue.GetTitle())
+	}
+	return fmt.Errorf("%d release blocker(s) remain in %s", len(blockers), milestone)
+}
+
+// isWaived reports whether labels contains a waiver for the pre-release
+// named by suffix (e.g. "beta1" waived by "okay-after-beta1", or by
+// waiverLabel if set). Waivers are specific to the pre-release they name,
+// so "beta2" does not inherit a waiver granted for "beta1".
+func isWaived(labels []string, suffix, waiverLabel string) bool {
+	if suffix == "" {
+		return false
+	}
+	return contains(waiverFor(waiverLabel, suffix), labels)
+}
+
+// splitRelease splits a release name such as "v1.2.0-beta1" into its
+// milestone ("v1.2.0") and pre-release suffix ("beta1"). Final releases
+// have no suffix.
+func splitRelease(release string) (milestone, suffix string) {
+	parts := strings.SplitN(release, "-", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+func printIssues(w io.Writer, issues []changelogIssue, markdownLinks bool) {
 	for _, issue := range issues {
 		if markdownLinks {
-			fmt.Fprintf(w, "- [#%d](%s): %s\n", issue.GetNumber(), issue.GetHTMLURL(), issue.GetTitle())
+			fmt.Fprintf(w, "- [#%d](%s): %s\n", issue.Number, issue.URL, issue.Title)
 		} else {
-			fmt.Fprintf(w, "- #%d: %s\n", issue.GetNumber(), issue.GetTitle())
+			fmt.Fprintf(w, "- #%d: %s\n", issue.Number, issue.Title)
 		}
 	}
 }
@@ -317,76 +698,146 @@ func contains(s string, ss []string) bool {
 	return false
 }
 
-func listCommits(ctx context.Context, client *github.Client, owner, repo, since, to string) ([]github.RepositoryCommit, error) {
-	commits, _, err := client.Repositories.CompareCommits(ctx, owner, repo, since, to)
-	if err != nil {
-		return nil, err
-	}
-	return commits.Commits, nil
+func listCommits(ctx context.Context, api githubAPI, owner, repo, since, to string) ([]github.RepositoryCommit, error) {
+	return api.CompareCommits(ctx, owner, repo, since, to)
 }
 
-func getFixes(commits []github.RepositoryCommit) []int {
-	fixesRe := regexp.MustCompile(`fixes #(\d+)`)
-	pullReqRe := regexp.MustCompile(`\(#(\d+)\)$`)
-	var fixes []int
-	seen := make(map[int]struct{})
+// defaultFixKeywords are the GitHub closing keywords recognized without any
+// --fix-keyword configuration.
+var defaultFixKeywords = []string{"close", "closes", "closed", "fix", "fixes", "fixed", "resolve", "resolves", "resolved"}
+
+// defaultTrailers are the commit-message keywords that reference an issue
+// without closing it, recognized without any --trailer configuration.
+var defaultTrailers = []string{"updates"}
+
+var pullReqRe = regexp.MustCompile(`\(#(\d+)\)$`)
+
+// getFixes scans commits for issues they close and issues they merely
+// reference. It understands the full set of GitHub closing keywords (plus
+// any extra ones registered with --fix-keyword), Syncthing's historical
+// "(#N)" pull-request-merge convention, and keyword references to other
+// repositories of the form "owner/repo#N" (counted only when they target
+// owner/repo). Keywords and cross-repo references are matched anywhere in
+// the commit message, not just its first line. fixes are issues to
+// milestone; updates are issues merely referenced (e.g. via the "updates"
+// trailer, or any keyword registered with --trailer) and are reported but
+// not milestoned.
+func getFixes(commits []github.RepositoryCommit, owner, repo string, fixKeywords, trailers []string) (fixes, updates []int) {
+	fixKeywords = append(append([]string{}, defaultFixKeywords...), fixKeywords...)
+	trailers = append(append([]string{}, defaultTrailers...), trailers...)
+
+	fixRe := keywordReferenceRegexp(fixKeywords)
+	updateRe := keywordReferenceRegexp(trailers)
+
+	seenFix := make(map[int]struct{})
+	seenUpdate := make(map[int]struct{})
+	addRef := func(dst *[]int, seen map[int]struct{}, num int) {
+		if _, ok := seen[num]; ok {
+			return
+		}
+		*dst = append(*dst, num)
+		seen[num] = struct{}{}
+	}
+
 	for _, commit := range commits {
 		msg := commit.Commit.GetMessage()
-		lines := strings.Split(msg, "\n")
-		msg = lines[0]
 
-		matches := fixesRe.FindAllStringSubmatch(msg, -1)
-		for _, m := range matches {
-			num, err := strconv.Atoi(m[1])
-			if err != nil {
-				continue // can't happen
+		for _, m := range fixRe.FindAllStringSubmatch(msg, -1) {
+			if num, ok := matchedIssue(m, owner, repo); ok {
+				addRef(&fixes, seenFix, num)
 			}
-			if _, ok := seen[num]; ok {
-				continue
+		}
+		for _, m := range updateRe.FindAllStringSubmatch(msg, -1) {
+			if num, ok := matchedIssue(m, owner, repo); ok {
+				addRef(&updates, seenUpdate, num)
 			}
-			fixes = append(fixes, num)
-			seen[num] = struct{}{}
 		}
 
-		match := pullReqRe.FindStringSubmatch(msg)
-		if len(match) == 2 {
-			num, err := strconv.Atoi(match[1])
-			if err != nil {
-				continue // can't happen
+		firstLine := strings.SplitN(msg, "\n", 2)[0]
+		if m := pullReqRe.FindStringSubmatch(firstLine); len(m) == 2 {
+			if num, err := strconv.Atoi(m[1]); err == nil {
+				addRef(&fixes, seenFix, num)
 			}
-			if _, ok := seen[num]; ok {
-				continue
-			}
-			fixes = append(fixes, num)
-			seen[num] = struct{}{}
 		}
 	}
+
 	sort.Ints(fixes)
-	return fixes
+	sort.Ints(updates)
+	return fixes, updates
 }
 
-func getMilestone(ctx context.Context, client *github.Client, owner, repo, name string) (*github.Milestone, error) {
-	opts := &github.MilestoneListOptions{State: "all"}
-	for {
-		stones, resp, err := client.Issues.ListMilestones(ctx, owner, repo, opts)
+// referencedIssues scans the commits between since and to for Updates-style
+// references (see getFixes) and returns the issues they name, for display
+// under changelog's "Also referenced" section. Issue numbers in exclude
+// (typically the issues already shown elsewhere in the changelog) are
+// skipped so an issue never appears twice, and pull requests and
+// skipLabels are filtered the same way the main changelog issue list is.
+// Referenced issues are reported only; referencedIssues never milestones
+// anything.
+func referencedIssues(ctx context.Context, api githubAPI, owner, repo, since, to string, fixKeywords, trailers, skipLabels []string, exclude map[int]struct{}) ([]changelogIssue, error) {
+	commits, err := listCommits(ctx, api, owner, repo, since, to)
+	if err != nil {
+		return nil, fmt.Errorf("listing commits: %w", err)
+	}
+
+	_, updates := getFixes(commits, owner, repo, fixKeywords, trailers)
+
+	var out []changelogIssue
+nextUpdate:
+	for _, num := range updates {
+		if _, ok := exclude[num]; ok {
+			continue
+		}
+		issue, err := api.GetIssue(ctx, owner, repo, num)
 		if err != nil {
-			return nil, err
+			log.Println("Getting issue:", err)
+			continue
 		}
-
-		var stone *github.Milestone
-		for _, stone = range stones {
-			if stone.GetTitle() == name {
-				return stone, nil
+		if issue.IsPullRequest() {
+			continue
+		}
+		for _, skip := range skipLabels {
+			if contains(skip, labels(issue)) {
+				continue nextUpdate
 			}
 		}
+		out = append(out, toChangelogIssue(issue))
+	}
+	return out, nil
+}
 
-		if resp.NextPage <= opts.Page {
-			break
-		}
+// keywordReferenceRegexp builds a case-insensitive regexp matching any of
+// the given keywords followed by either a plain "#N" issue reference or a
+// cross-repo "owner/repo#N" reference.
+func keywordReferenceRegexp(keywords []string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(keywords, "|") + `)\b:?\s+(?:([\w.-]+/[\w.-]+)#(\d+)|#(\d+))`)
+}
 
-		opts.Page = resp.NextPage
+// matchedIssue extracts the issue number from a keywordReferenceRegexp
+// submatch, honoring cross-repo references only when they target
+// owner/repo.
+func matchedIssue(m []string, owner, repo string) (int, bool) {
+	if m[1] != "" {
+		if m[1] != owner+"/"+repo {
+			return 0, false
+		}
+		n, err := strconv.Atoi(m[2])
+		return n, err == nil
 	}
+	n, err := strconv.Atoi(m[3])
+	return n, err == nil
+}
 
+func getMilestone(ctx context.Context, api githubAPI, owner, repo, name string) (*github.Milestone, error) {
+	stones, err := api.ListMilestones(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, stone := range stones {
+		if stone.GetTitle() == name {
+			return stone, nil
+		}
+	}
 	return nil, errors.New("not found")
 }
 
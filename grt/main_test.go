@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func commitWithMessage(msg string) github.RepositoryCommit {
+	return github.RepositoryCommit{
+		Commit: &github.Commit{Message: github.String(msg)},
+	}
+}
+
+func TestGetFixesExtractsAndDedups(t *testing.T) {
+	commits := []github.RepositoryCommit{
+		commitWithMessage("lib: fix the thing (fixes #12)"),
+		commitWithMessage("gui: add a widget (#34)"),
+		commitWithMessage("lib: fix the thing, again (fixes #12)\n\nLonger body referencing #99 too."),
+	}
+
+	fixes, updates := getFixes(commits, "o", "r", nil, nil)
+	want := []int{12, 34}
+	if len(fixes) != len(want) {
+		t.Fatalf("getFixes() fixes = %v, want %v", fixes, want)
+	}
+	for i := range want {
+		if fixes[i] != want[i] {
+			t.Errorf("getFixes() fixes[%d] = %d, want %d", i, fixes[i], want[i])
+		}
+	}
+	if len(updates) != 0 {
+		t.Errorf("getFixes() updates = %v, want none (no recognized trailer in the test commits)", updates)
+	}
+}
+
+func TestGetFixesHonorsKeywordsCrossRepoAndTrailers(t *testing.T) {
+	commits := []github.RepositoryCommit{
+		commitWithMessage("lib: resolve the thing\n\nResolves: #12"),
+		commitWithMessage("lib: ref another repo's bug\n\nFixes other/repo#34"),
+		commitWithMessage("lib: tidy up\n\nUpdates #56"),
+		commitWithMessage("lib: syncthing style\n\nAlso ref #78"),
+	}
+
+	fixes, updates := getFixes(commits, "o", "r", []string{"ref"}, nil)
+	wantFixes := []int{12, 78}
+	if len(fixes) != len(wantFixes) {
+		t.Fatalf("getFixes() fixes = %v, want %v", fixes, wantFixes)
+	}
+	for i := range wantFixes {
+		if fixes[i] != wantFixes[i] {
+			t.Errorf("getFixes() fixes[%d] = %d, want %d", i, fixes[i], wantFixes[i])
+		}
+	}
+
+	wantUpdates := []int{56}
+	if len(updates) != len(wantUpdates) || updates[0] != wantUpdates[0] {
+		t.Errorf("getFixes() updates = %v, want %v", updates, wantUpdates)
+	}
+
+	// The cross-repo reference targets "other/repo", not "o/r", so it must
+	// not be counted.
+	for _, n := range fixes {
+		if n == 34 {
+			t.Errorf("getFixes() should not count a cross-repo reference to another repository, got %v", fixes)
+		}
+	}
+}
+
+func TestCreateMilestoneAutoCreates(t *testing.T) {
+	api := newFakeAPI()
+	closed := api.addIssue(12, "closed", nil)
+	api.commits = []github.RepositoryCommit{commitWithMessage("fix the thing (fixes #12)")}
+
+	if err := createMilestone(context.Background(), api, "o", "r", "v1", "HEAD", "v1.2.0", false, false, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stones, _ := api.ListMilestones(context.Background(), "o", "r")
+	if len(stones) != 1 || stones[0].GetTitle() != "v1.2.0" {
+		t.Fatalf("expected v1.2.0 milestone to be auto-created, got %v", stones)
+	}
+	if closed.Milestone.GetTitle() != "v1.2.0" {
+		t.Errorf("expected issue #12 to be milestoned, got %v", closed.Milestone)
+	}
+}
+
+func TestCreateMilestoneForceOverwritesExisting(t *testing.T) {
+	api := newFakeAPI()
+	other := api.addMilestone("v1.1.0", "open")
+	target := api.addMilestone("v1.2.0", "open")
+	issue := api.addIssue(12, "closed", other)
+	api.commits = []github.RepositoryCommit{commitWithMessage("fix the thing (fixes #12)")}
+
+	if err := createMilestone(context.Background(), api, "o", "r", "v1", "HEAD", "v1.2.0", false, false, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if issue.Milestone.GetNumber() != other.GetNumber() {
+		t.Fatalf("without --force, issue should stay on its existing milestone")
+	}
+
+	if err := createMilestone(context.Background(), api, "o", "r", "v1", "HEAD", "v1.2.0", true, false, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if issue.Milestone.GetNumber() != target.GetNumber() {
+		t.Errorf("with --force, issue should be moved to v1.2.0, got %v", issue.Milestone)
+	}
+}
+
+func TestCreateMilestoneSkipsOpenIssues(t *testing.T) {
+	api := newFakeAPI()
+	issue := api.addIssue(12, "open", nil)
+	api.commits = []github.RepositoryCommit{commitWithMessage("fix the thing (fixes #12)")}
+
+	if err := createMilestone(context.Background(), api, "o", "r", "v1", "HEAD", "v1.2.0", false, false, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if issue.Milestone != nil {
+		t.Errorf("open issue should not be milestoned, got %v", issue.Milestone)
+	}
+}
+
+func TestCheckBlockersFiltersWaivedAndClosed(t *testing.T) {
+	api := newFakeAPI()
+	stone := api.addMilestone("v1.2.0", "open")
+	api.addIssue(1, "open", stone, "release-blocker")
+	api.addIssue(2, "open", stone, "release-blocker", "okay-after-beta1")
+	api.addIssue(3, "closed", stone, "release-blocker")
+	api.addIssue(4, "open", stone)
+
+	err := checkBlockers(context.Background(), api, "o", "r", "v1.2.0-beta1", nil, "")
+	if err == nil {
+		t.Fatal("expected an error reporting the unresolved blocker")
+	}
+
+	if err := checkBlockers(context.Background(), api, "o", "r", "v1.2.0-beta1", []int{1}, ""); err != nil {
+		t.Errorf("allowed blocker should not fail check, got %v", err)
+	}
+}
+
+func TestCheckBlockersHonorsCustomWaiverLabel(t *testing.T) {
+	api := newFakeAPI()
+	stone := api.addMilestone("v1.2.0", "open")
+	api.addIssue(1, "open", stone, "release-blocker", "ready")
+
+	if err := checkBlockers(context.Background(), api, "o", "r", "v1.2.0-beta1", nil, "ready"); err != nil {
+		t.Errorf("custom waiver label should satisfy check, got %v", err)
+	}
+	if err := checkBlockers(context.Background(), api, "o", "r", "v1.2.0-beta1", nil, ""); err == nil {
+		t.Error("default waiver label should not match a custom one")
+	}
+}
+
+func TestCreateReleaseDetectsPrerelease(t *testing.T) {
+	api := newFakeAPI()
+	api.addMilestone("v1.2.0", "open")
+
+	if err := createRelease(context.Background(), api, "o", "r", "v1.2.0-beta1", "", "", "notes"); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.releases) != 1 || !api.releases[0].GetPrerelease() {
+		t.Fatalf("expected a prerelease to be created, got %v", api.releases)
+	}
+
+	if err := createRelease(context.Background(), api, "o", "r", "v1.2.0", "v1.3.0", "", "notes"); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.releases) != 2 || api.releases[1].GetPrerelease() {
+		t.Fatalf("expected a final release to be created, got %v", api.releases)
+	}
+}
+
+func TestCreateReleaseRejectsFinalWithoutNext(t *testing.T) {
+	api := newFakeAPI()
+	api.addMilestone("v1.2.0", "open")
+
+	if err := createRelease(context.Background(), api, "o", "r", "v1.2.0", "", "", "notes"); err == nil {
+		t.Fatal("expected an error for a final release with no --next")
+	}
+	if len(api.releases) != 0 {
+		t.Fatalf("release should not be published when --next is missing, got %v", api.releases)
+	}
+}